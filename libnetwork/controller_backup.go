@@ -0,0 +1,52 @@
+package libnetwork
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/libnetwork/datastore"
+)
+
+// SnapshotNetworkDB writes a JSON snapshot of the local network datastore
+// (networks, endpoints, IPAM pools and allocator state) to path, so it can
+// be used for backup or migrated to another host with RestoreNetworkDB,
+// instead of copying the boltdb file directly.
+func (c *Controller) SnapshotNetworkDB(path string) error {
+	store := c.getStore(datastore.LocalScope)
+	if store == nil {
+		return fmt.Errorf("no local datastore configured")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create network database snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := store.Export(f); err != nil {
+		return fmt.Errorf("failed to snapshot network database: %w", err)
+	}
+	return f.Close()
+}
+
+// RestoreNetworkDB restores the local network datastore from a snapshot
+// previously written by SnapshotNetworkDB. It fails rather than overwrite
+// any record already present in the store; wipe the existing datastore
+// first if a clean restore is required.
+func (c *Controller) RestoreNetworkDB(path string) error {
+	store := c.getStore(datastore.LocalScope)
+	if store == nil {
+		return fmt.Errorf("no local datastore configured")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open network database snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := store.Import(f, false); err != nil {
+		return fmt.Errorf("failed to restore network database: %w", err)
+	}
+	return nil
+}
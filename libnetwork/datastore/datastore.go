@@ -8,7 +8,6 @@ import (
 
 	"github.com/docker/docker/libnetwork/discoverapi"
 	store "github.com/docker/docker/libnetwork/internal/kvstore"
-	"github.com/docker/docker/libnetwork/internal/kvstore/boltdb"
 	"github.com/docker/docker/libnetwork/scope"
 	"github.com/docker/docker/libnetwork/types"
 )
@@ -24,6 +23,10 @@ type Store struct {
 	scope string
 	store store.Store
 	cache *cache
+
+	watchInterval time.Duration
+	watchMu       sync.Mutex
+	watches       []*watch
 }
 
 // KVObject is Key/Value interface used by objects to be part of the Store.
@@ -69,6 +72,23 @@ type ScopeClientCfg struct {
 	Provider string
 	Address  string
 	Config   *store.Config
+
+	// WatchInterval is the polling interval used to emulate Watch/RestartWatch
+	// on backends, such as boltdb, that have no native change notifications.
+	// Defaults to defaultWatchInterval when zero.
+	WatchInterval time.Duration
+
+	// EncryptionKey, if set, AES-256-GCM encrypts every value before it is
+	// written to the store and decrypts it on read, so a copy of the
+	// underlying boltdb file does not expose network configs, endpoint
+	// secrets or IPAM state in cleartext. It is used as key id 0. To
+	// rotate keys while still being able to decrypt values written under
+	// a previous key, set KeyProvider instead.
+	EncryptionKey []byte
+
+	// KeyProvider, if set, takes precedence over EncryptionKey and
+	// supports key rotation.
+	KeyProvider KeyProvider
 }
 
 const (
@@ -144,22 +164,41 @@ func Key(key ...string) string {
 }
 
 // newClient used to connect to KV Store
-func newClient(kv string, addr string, config *store.Config) (*Store, error) {
-	if kv != string(store.BOLTDB) {
+func newClient(cfg ScopeClientCfg) (*Store, error) {
+	driversMu.Lock()
+	ctor, ok := drivers[cfg.Provider]
+	driversMu.Unlock()
+	if !ok {
 		return nil, fmt.Errorf("unsupported KV store")
 	}
 
+	config := cfg.Config
 	if config == nil {
 		config = &store.Config{}
 	}
 
-	// Parse file path
-	s, err := boltdb.New(strings.Split(addr, ","), config)
+	s, err := ctor(strings.Split(cfg.Address, ","), config)
 	if err != nil {
 		return nil, err
 	}
 
-	ds := &Store{scope: scope.Local, store: s}
+	kp := cfg.KeyProvider
+	if kp == nil && len(cfg.EncryptionKey) > 0 {
+		kp = staticKeyProvider{key: cfg.EncryptionKey}
+	}
+	if kp != nil {
+		s, err = newEncryptedStore(s, kp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	watchInterval := cfg.WatchInterval
+	if watchInterval <= 0 {
+		watchInterval = defaultWatchInterval
+	}
+
+	ds := &Store{scope: scope.Local, store: s, watchInterval: watchInterval}
 	ds.cache = newCache(ds)
 
 	return ds, nil
@@ -171,7 +210,7 @@ func New(cfg ScopeCfg) (*Store, error) {
 		cfg = DefaultScope("")
 	}
 
-	return newClient(cfg.Client.Provider, cfg.Client.Address, cfg.Client.Config)
+	return newClient(cfg.Client)
 }
 
 // FromConfig creates a new instance of LibKV data store starting from the datastore config data.
@@ -0,0 +1,225 @@
+package datastore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+// KeyProvider supplies the AES-256-GCM keys used to encrypt values written
+// to, and decrypt values read from, an encrypted Store. Implement it to
+// source keys from a host keyring, or to support rotating the active key
+// while retaining older keys for decrypt-only access. ScopeClientCfg.EncryptionKey
+// is a convenience for the common case of a single, static key.
+type KeyProvider interface {
+	// ActiveKeyID returns the id that identifies ActiveKey. It is stored
+	// alongside every value written with that key, so Key can later look
+	// the right key back up after a rotation.
+	ActiveKeyID() byte
+	// ActiveKey returns the 32-byte AES-256 key used to encrypt new
+	// writes.
+	ActiveKey() []byte
+	// Key returns the key previously registered under id, to decrypt
+	// values written before a rotation. It returns ErrKeyNotFound if id
+	// is unknown.
+	Key(id byte) ([]byte, error)
+}
+
+// staticKeyProvider implements KeyProvider over ScopeClientCfg.EncryptionKey,
+// a single key used both to encrypt and decrypt, with no rotation.
+type staticKeyProvider struct {
+	key []byte
+}
+
+func (p staticKeyProvider) ActiveKeyID() byte { return 0 }
+func (p staticKeyProvider) ActiveKey() []byte { return p.key }
+
+func (p staticKeyProvider) Key(id byte) ([]byte, error) {
+	if id == 0 {
+		return p.key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// encryptedStore wraps a store.Store so that every value is AES-256-GCM
+// encrypted before it reaches the wrapped Put/AtomicPut, and decrypted
+// after it comes back from Get/List. Each encrypted value is prefixed with
+// the id of the key used to seal it, so Key rotation can still decrypt
+// values written under a retired key, followed by a nonce generated fresh
+// for that write - a random nonce is simpler to get right than one
+// derived solely from the kv key, since the same kv key is written many
+// times over its life and GCM nonces must never repeat under a given key.
+type encryptedStore struct {
+	inner store.Store
+	kp    KeyProvider
+}
+
+// newEncryptedStore wraps inner so that every value it stores is encrypted
+// under kp's active key.
+func newEncryptedStore(inner store.Store, kp KeyProvider) (*encryptedStore, error) {
+	if l := len(kp.ActiveKey()); l != 32 {
+		return nil, fmt.Errorf("datastore: encryption key must be 32 bytes for AES-256, got %d", l)
+	}
+	return &encryptedStore{inner: inner, kp: kp}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("datastore: failed to initialize AES cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under the active key, returning keyID || nonce || ciphertext.
+// An empty plaintext is returned unchanged, without being sealed.
+func (s *encryptedStore) seal(plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return plaintext, nil
+	}
+
+	gcm, err := newGCM(s.kp.ActiveKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("datastore: failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, 1+len(nonce)+gcm.Overhead()+len(plaintext))
+	out = append(out, s.kp.ActiveKeyID())
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// open decrypts a value previously produced by seal, looking up the key
+// registered under its leading key-id byte.
+func (s *encryptedStore) open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return ciphertext, nil
+	}
+
+	keyID, rest := ciphertext[0], ciphertext[1:]
+
+	key := s.kp.ActiveKey()
+	if keyID != s.kp.ActiveKeyID() {
+		var err error
+		key, err = s.kp.Key(keyID)
+		if err != nil {
+			return nil, fmt.Errorf("datastore: failed to decrypt value sealed with key id %d: %w", keyID, err)
+		}
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("datastore: encrypted value too short")
+	}
+
+	nonce, sealed := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("datastore: failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (s *encryptedStore) decryptPair(pair *store.KVPair) (*store.KVPair, error) {
+	if pair == nil {
+		return nil, nil
+	}
+	plain, err := s.open(pair.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &store.KVPair{Key: pair.Key, Value: plain, LastIndex: pair.LastIndex}, nil
+}
+
+func (s *encryptedStore) Get(key string) (*store.KVPair, error) {
+	pair, err := s.inner.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptPair(pair)
+}
+
+func (s *encryptedStore) Put(key string, value []byte) error {
+	sealed, err := s.seal(value)
+	if err != nil {
+		return err
+	}
+	return s.inner.Put(key, sealed)
+}
+
+func (s *encryptedStore) Exists(key string) (bool, error) {
+	return s.inner.Exists(key)
+}
+
+func (s *encryptedStore) List(directory string) ([]*store.KVPair, error) {
+	pairs, err := s.inner.List(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*store.KVPair, 0, len(pairs))
+	for _, pair := range pairs {
+		decrypted, err := s.decryptPair(pair)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decrypted)
+	}
+	return out, nil
+}
+
+func (s *encryptedStore) AtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error) {
+	sealed, err := s.seal(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.inner.AtomicPut(key, sealed, previous)
+}
+
+func (s *encryptedStore) AtomicDelete(key string, previous *store.KVPair) error {
+	return s.inner.AtomicDelete(key, previous)
+}
+
+func (s *encryptedStore) Close() {
+	s.inner.Close()
+}
+
+// sealedAccess is implemented by store.Store wrappers that transform
+// values before they reach the backend - today, only encryptedStore. It
+// lets backup code such as Export/Import read and write the bytes that
+// actually get persisted to disk, instead of going through the normal
+// Get/Put/List/AtomicPut, which would hand back decrypted plaintext, or
+// encrypt an already-sealed value a second time.
+type sealedAccess interface {
+	sealedList(directory string) ([]*store.KVPair, error)
+	sealedPut(key string, value []byte) error
+	sealedAtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error)
+}
+
+// sealedList returns every KVPair under directory exactly as it is stored
+// on disk, still sealed.
+func (s *encryptedStore) sealedList(directory string) ([]*store.KVPair, error) {
+	return s.inner.List(directory)
+}
+
+// sealedPut writes value as-is, without sealing it again.
+func (s *encryptedStore) sealedPut(key string, value []byte) error {
+	return s.inner.Put(key, value)
+}
+
+// sealedAtomicPut writes value as-is, without sealing it again.
+func (s *encryptedStore) sealedAtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error) {
+	return s.inner.AtomicPut(key, value, previous)
+}
@@ -0,0 +1,100 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+)
+
+// rotatingKeyProvider is a KeyProvider with more than one registered key, so
+// tests can exercise decrypting values sealed under a retired key.
+type rotatingKeyProvider struct {
+	activeID byte
+	keys     map[byte][]byte
+}
+
+func (p *rotatingKeyProvider) ActiveKeyID() byte { return p.activeID }
+func (p *rotatingKeyProvider) ActiveKey() []byte { return p.keys[p.activeID] }
+
+func (p *rotatingKeyProvider) Key(id byte) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	kp := staticKeyProvider{key: bytes.Repeat([]byte{0x11}, 32)}
+	es, err := newEncryptedStore(newMemStore(), kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("endpoint-secret")
+	if err := es.Put("k1", plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	// The value landed in the inner store must be sealed, not the
+	// original plaintext.
+	raw, err := es.inner.Get("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(raw.Value, plaintext) {
+		t.Fatal("encryptedStore.Put stored plaintext")
+	}
+
+	got, err := es.Get("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Value, plaintext) {
+		t.Fatalf("got %q, want %q", got.Value, plaintext)
+	}
+}
+
+func TestEncryptedStoreKeyRotation(t *testing.T) {
+	kp := &rotatingKeyProvider{
+		activeID: 1,
+		keys: map[byte][]byte{
+			0: bytes.Repeat([]byte{0xAA}, 32),
+			1: bytes.Repeat([]byte{0xBB}, 32),
+		},
+	}
+	inner := newMemStore()
+	es, err := newEncryptedStore(inner, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("written-before-rotation")
+	if err := es.Put("k1", plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: key id 0 becomes active, but the value written above was
+	// sealed under key id 1, which must still be reachable via Key().
+	kp.activeID = 0
+
+	got, err := es.Get("k1")
+	if err != nil {
+		t.Fatalf("failed to decrypt value sealed under a retired key after rotation: %v", err)
+	}
+	if !bytes.Equal(got.Value, plaintext) {
+		t.Fatalf("got %q, want %q", got.Value, plaintext)
+	}
+
+	// A write made after rotation must be sealed under the new active key.
+	newPlaintext := []byte("written-after-rotation")
+	if err := es.Put("k2", newPlaintext); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := inner.Get("k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Value[0] != 0 {
+		t.Fatalf("value written after rotation was sealed with key id %d, want 0", raw.Value[0])
+	}
+}
@@ -0,0 +1,152 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+// exportSchemaVersion is written as the first line of every Export, and
+// checked by Import, so that future changes to the on-disk record format
+// can be detected instead of silently misread.
+const exportSchemaVersion = 1
+
+// exportHeader is the first newline-delimited JSON record written by
+// Export.
+type exportHeader struct {
+	Version int `json:"version"`
+}
+
+// exportRecord is a single (key, raw value, LastIndex) tuple, as written by
+// Export for every record found under rootChain.
+type exportRecord struct {
+	Key       string `json:"key"`
+	Value     []byte `json:"value"`
+	LastIndex uint64 `json:"last_index"`
+}
+
+// Export walks every record under rootChain - networks, endpoints, IPAM
+// pools, bitseq allocator state and so on - and writes it to w as a schema
+// version header followed by one JSON record per line. The result can
+// later be handed to Import to recreate the store, for backup or
+// migration between hosts.
+//
+// If the store is encrypted at rest, Export writes the sealed bytes as
+// they exist on disk, never the decrypted plaintext - otherwise the
+// exported snapshot would expose network configs, endpoint secrets and
+// IPAM state that the encrypted store exists to protect.
+func (ds *Store) Export(w io.Writer) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(exportHeader{Version: exportSchemaVersion}); err != nil {
+		return fmt.Errorf("datastore: failed to write export header: %w", err)
+	}
+
+	var (
+		pairs []*store.KVPair
+		err   error
+	)
+	if sealed, ok := ds.store.(sealedAccess); ok {
+		pairs, err = sealed.sealedList(Key())
+	} else {
+		pairs, err = ds.store.List(Key())
+	}
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+
+	for _, pair := range pairs {
+		if len(pair.Value) == 0 {
+			continue
+		}
+		rec := exportRecord{Key: pair.Key, Value: pair.Value, LastIndex: pair.LastIndex}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("datastore: failed to write record for key %q: %w", pair.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Import reads a stream produced by Export and re-creates every record it
+// contains. By default each record is written with AtomicPut and no
+// previous value, so Import fails with ErrKeyModified the moment it hits a
+// key that already exists in the store. Passing overwrite=true instead
+// writes every record with a raw Put, replacing whatever is already
+// there.
+//
+// exportRecord.Value is the sealed, on-disk byte string when the store is
+// encrypted at rest (see Export), so Import writes it back through the
+// same sealed path rather than the normal Put/AtomicPut - otherwise it
+// would be sealed a second time and fail to decrypt on the next read.
+//
+// Import writes every record straight to the store, bypassing ds.cache:
+// records are restored as raw (key, value) tuples, with no KVObject to
+// hand cache.add/del, so on success Import rebuilds ds.cache from
+// scratch, the same way a process restart would. Without this,
+// GetObject/List/Map - which, once ds.cache is non-nil, never fall back
+// to the store - would keep serving whatever was cached before the
+// import and stay blind to the restored records until the process
+// restarted.
+func (ds *Store) Import(r io.Reader, overwrite bool) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	dec := json.NewDecoder(r)
+
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("datastore: failed to read export header: %w", err)
+	}
+	if header.Version != exportSchemaVersion {
+		return fmt.Errorf("datastore: unsupported export schema version %d", header.Version)
+	}
+
+	sealed, isSealed := ds.store.(sealedAccess)
+
+	for dec.More() {
+		var rec exportRecord
+		if err := dec.Decode(&rec); err != nil {
+			return fmt.Errorf("datastore: failed to read export record: %w", err)
+		}
+
+		if overwrite {
+			var err error
+			if isSealed {
+				err = sealed.sealedPut(rec.Key, rec.Value)
+			} else {
+				err = ds.store.Put(rec.Key, rec.Value)
+			}
+			if err != nil {
+				return fmt.Errorf("datastore: failed to restore key %q: %w", rec.Key, err)
+			}
+			continue
+		}
+
+		var err error
+		if isSealed {
+			_, err = sealed.sealedAtomicPut(rec.Key, rec.Value, nil)
+		} else {
+			_, err = ds.store.AtomicPut(rec.Key, rec.Value, nil)
+		}
+		if err != nil {
+			if err == store.ErrKeyExists {
+				return fmt.Errorf("datastore: key %q already exists, pass overwrite=true to replace it: %w", rec.Key, ErrKeyModified)
+			}
+			return fmt.Errorf("datastore: failed to restore key %q: %w", rec.Key, err)
+		}
+	}
+
+	if ds.cache != nil {
+		ds.cache = newCache(ds)
+	}
+
+	return nil
+}
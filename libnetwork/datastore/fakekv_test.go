@@ -0,0 +1,46 @@
+package datastore
+
+// fakeKV is a minimal KVObject/KVConstructor used by tests that need a
+// concrete object to put/get/delete/watch, without pulling in any of the
+// real network/endpoint types.
+type fakeKV struct {
+	prefix string
+	name   string
+	value  []byte
+	index  uint64
+	exists bool
+}
+
+func (f *fakeKV) Key() []string       { return []string{f.prefix, f.name} }
+func (f *fakeKV) KeyPrefix() []string { return []string{f.prefix} }
+func (f *fakeKV) Value() []byte       { return f.value }
+
+func (f *fakeKV) SetValue(v []byte) error {
+	f.value = make([]byte, len(v))
+	copy(f.value, v)
+	// The fake's "decoded" name is just its value, so child objects
+	// reconstructed from a store listing still hash back to the same key.
+	f.name = string(v)
+	return nil
+}
+
+func (f *fakeKV) Index() uint64 { return f.index }
+func (f *fakeKV) SetIndex(i uint64) {
+	f.index = i
+	f.exists = true
+}
+func (f *fakeKV) Exists() bool      { return f.exists }
+func (f *fakeKV) DataScope() string { return LocalScope }
+func (f *fakeKV) Skip() bool        { return false }
+
+func (f *fakeKV) New() KVObject {
+	return &fakeKV{prefix: f.prefix}
+}
+
+func (f *fakeKV) CopyTo(o KVObject) error {
+	dst := o.(*fakeKV)
+	dst.prefix, dst.name, dst.index, dst.exists = f.prefix, f.name, f.index, f.exists
+	dst.value = make([]byte, len(f.value))
+	copy(dst.value, f.value)
+	return nil
+}
@@ -0,0 +1,99 @@
+package datastore
+
+import (
+	"strings"
+	"sync"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+// memStore is a minimal in-memory store.Store used to exercise datastore
+// logic in tests without a real boltdb file.
+type memStore struct {
+	mu    sync.Mutex
+	data  map[string]*store.KVPair
+	index uint64
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]*store.KVPair)}
+}
+
+func (m *memStore) nextIndex() uint64 {
+	m.index++
+	return m.index
+}
+
+func (m *memStore) Get(key string) (*store.KVPair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pair, ok := m.data[key]
+	if !ok {
+		return nil, store.ErrKeyNotFound
+	}
+	cp := *pair
+	return &cp, nil
+}
+
+func (m *memStore) Put(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = &store.KVPair{Key: key, Value: value, LastIndex: m.nextIndex()}
+	return nil
+}
+
+func (m *memStore) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.data[key]
+	return ok, nil
+}
+
+func (m *memStore) List(dir string) ([]*store.KVPair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*store.KVPair
+	for k, pair := range m.data {
+		if strings.HasPrefix(k, dir) {
+			cp := *pair
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) AtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.data[key]
+	switch {
+	case previous == nil && ok:
+		return nil, store.ErrKeyExists
+	case previous != nil && (!ok || current.LastIndex != previous.LastIndex):
+		return nil, store.ErrKeyExists
+	}
+
+	pair := &store.KVPair{Key: key, Value: value, LastIndex: m.nextIndex()}
+	m.data[key] = pair
+	cp := *pair
+	return &cp, nil
+}
+
+func (m *memStore) AtomicDelete(key string, previous *store.KVPair) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.data[key]
+	if !ok || previous == nil || current.LastIndex != previous.LastIndex {
+		return store.ErrKeyExists
+	}
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memStore) Close() {}
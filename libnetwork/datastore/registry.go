@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"sync"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+	"github.com/docker/docker/libnetwork/internal/kvstore/boltdb"
+)
+
+// Driver constructs a store.Store for the given list of backend addresses
+// and configuration. Drivers register a Driver under their provider name by
+// calling Register, typically from an init function.
+type Driver func(addrs []string, cfg *store.Config) (store.Store, error)
+
+var (
+	driversMu sync.Mutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a KV store backend available under the given provider
+// name for use by New and FromConfig. It is intended to be called from a
+// driver package's init function, for example:
+//
+//	func init() {
+//		datastore.Register("etcd", New)
+//	}
+//
+// Register panics if called twice with the same provider name, or if ctor
+// is nil.
+func Register(provider string, ctor Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if ctor == nil {
+		panic("datastore: Register called with nil Driver for provider " + provider)
+	}
+	if _, dup := drivers[provider]; dup {
+		panic("datastore: Register called twice for provider " + provider)
+	}
+	drivers[provider] = ctor
+}
+
+func init() {
+	Register(string(store.BOLTDB), func(addrs []string, cfg *store.Config) (store.Store, error) {
+		return boltdb.New(addrs, cfg)
+	})
+}
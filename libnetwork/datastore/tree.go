@@ -0,0 +1,244 @@
+package datastore
+
+import (
+	"fmt"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+	"github.com/docker/docker/libnetwork/types"
+)
+
+// DeleteTree atomically deletes kvObject and every child object found
+// under its KeyPrefix() - for example a network and all of its endpoints.
+// Every child's LastIndex, as last observed by the store, is checked
+// before anything is removed; if any child has changed since it was last
+// read, DeleteTree deletes nothing and returns ErrKeyModified. Like
+// DeleteObjectAtomic, the cache is kept in sync with every child removed.
+func (ds *Store) DeleteTree(kvObject KVObject) error {
+	if kvObject == nil {
+		return types.BadRequestErrorf("invalid KV Object : nil")
+	}
+
+	ctor, ok := kvObject.(KVConstructor)
+	if !ok {
+		return fmt.Errorf("error deleting tree, object does not implement KVConstructor interface")
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	prefix := Key(kvObject.KeyPrefix()...)
+	children, err := ds.store.List(prefix)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+
+	b := ds.newBatch()
+	for _, child := range children {
+		if len(child.Value) == 0 {
+			continue
+		}
+
+		o := ctor.New()
+		if err := o.SetValue(child.Value); err != nil {
+			return err
+		}
+		o.SetIndex(child.LastIndex)
+
+		b.Delete(o)
+	}
+
+	return b.commit()
+}
+
+// rawOp is a single staged Batch operation. kvObject is optional: DeleteTree
+// stages deletes for children it reconstructed from the store, which have a
+// KVObject to update the index/cache of just like any caller-staged op.
+type rawOp struct {
+	key         string
+	value       []byte
+	lastIndex   uint64
+	hasPrevious bool
+	isPut       bool
+	kvObject    KVObject
+}
+
+// Batch lets a caller stage multiple PutObjectAtomic/DeleteObjectAtomic
+// calls and apply them together with Commit.
+//
+// Commit holds the store's lock for the whole batch, so from any other
+// caller's point of view the batch lands, or fails, as a single unit:
+// every staged operation is applied with the same optimistic-concurrency
+// check AtomicPut/AtomicDelete perform individually, and if any of them
+// finds a stale LastIndex, Commit rolls back every operation it already
+// applied and returns ErrKeyModified rather than leaving the store
+// partially updated. Once every operation has committed to the store,
+// Commit updates each staged object's Index and the cache exactly as
+// PutObjectAtomic/DeleteObjectAtomic do, so GetObject/List/Map never see
+// stale entries for a batch that has already landed.
+type Batch struct {
+	ds  *Store
+	ops []rawOp
+}
+
+// NewBatch returns an empty Batch bound to ds.
+func (ds *Store) NewBatch() *Batch {
+	return ds.newBatch()
+}
+
+func (ds *Store) newBatch() *Batch {
+	return &Batch{ds: ds}
+}
+
+// Put stages an atomic put of kvObject.
+func (b *Batch) Put(kvObject KVObject) {
+	b.ops = append(b.ops, rawOp{
+		key:         Key(kvObject.Key()...),
+		value:       kvObject.Value(),
+		lastIndex:   kvObject.Index(),
+		hasPrevious: kvObject.Exists(),
+		isPut:       true,
+		kvObject:    kvObject,
+	})
+}
+
+// Delete stages an atomic delete of kvObject.
+func (b *Batch) Delete(kvObject KVObject) {
+	b.ops = append(b.ops, rawOp{
+		key:         Key(kvObject.Key()...),
+		lastIndex:   kvObject.Index(),
+		hasPrevious: true,
+		kvObject:    kvObject,
+	})
+}
+
+// Commit applies every staged operation. ds.mu is held for the duration of
+// the call, so the batch is applied atomically with respect to every other
+// Store method.
+func (b *Batch) Commit() error {
+	b.ds.mu.Lock()
+	defer b.ds.mu.Unlock()
+
+	return b.commit()
+}
+
+// undoOp describes how to reverse one already-applied rawOp.
+type undoOp struct {
+	key       string
+	restore   bool // true: put value back; false: the key was newly created, remove it
+	value     []byte
+	lastIndex uint64
+}
+
+// commit is the body of Commit. Callers must hold ds.mu.
+func (b *Batch) commit() error {
+	ds := b.ds
+
+	// First pass: apply every op to the store, rolling back everything
+	// already applied the moment one of them hits a stale LastIndex. The
+	// cache is deliberately left untouched here - if it contained a
+	// partial rollback of the batch, a concurrent reader could observe a
+	// state the store never actually had.
+	var undo []undoOp
+	newIndexes := make([]uint64, len(b.ops))
+	for i, op := range b.ops {
+		var previous *store.KVPair
+		if op.hasPrevious {
+			previous = &store.KVPair{Key: op.key, LastIndex: op.lastIndex}
+		}
+
+		// Capture the pre-operation value so a later failure can be
+		// rolled back, regardless of whether this op succeeds.
+		preimage, hadPreimage, err := ds.getRawLocked(op.key)
+		if err != nil {
+			ds.rollbackLocked(undo)
+			return err
+		}
+
+		if op.isPut {
+			pair, err := ds.store.AtomicPut(op.key, op.value, previous)
+			if err != nil {
+				ds.rollbackLocked(undo)
+				if err == store.ErrKeyExists {
+					return ErrKeyModified
+				}
+				return err
+			}
+			newIndexes[i] = pair.LastIndex
+		} else {
+			if err := ds.store.AtomicDelete(op.key, previous); err != nil {
+				ds.rollbackLocked(undo)
+				if err == store.ErrKeyExists {
+					return ErrKeyModified
+				}
+				return err
+			}
+		}
+
+		if hadPreimage {
+			undo = append(undo, undoOp{key: op.key, restore: true, value: preimage.Value})
+		} else {
+			undo = append(undo, undoOp{key: op.key, restore: false, lastIndex: newIndexes[i]})
+		}
+	}
+
+	// Second pass: every op has landed in the store, so it is now safe to
+	// bring each staged object's Index, and the cache, up to date -
+	// mirroring what PutObjectAtomic/DeleteObjectAtomic do for a single
+	// object.
+	for i, op := range b.ops {
+		if op.kvObject == nil {
+			continue
+		}
+
+		if op.isPut {
+			op.kvObject.SetIndex(newIndexes[i])
+		}
+
+		if ds.cache == nil {
+			continue
+		}
+
+		var err error
+		if op.isPut {
+			err = ds.cache.add(op.kvObject, op.kvObject.Skip())
+		} else {
+			err = ds.cache.del(op.kvObject, op.kvObject.Skip())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getRawLocked fetches the raw, current value of key, reporting
+// hadPrevious == false rather than an error when key does not exist.
+// Callers must hold ds.mu.
+func (ds *Store) getRawLocked(key string) (pair *store.KVPair, hadPrevious bool, err error) {
+	pair, err = ds.store.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return pair, true, nil
+}
+
+// rollbackLocked undoes every entry in undo, in reverse order, on a
+// best-effort basis: errors are ignored, since there is no further
+// fallback once a partially-applied batch needs to be unwound.
+func (ds *Store) rollbackLocked(undo []undoOp) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		op := undo[i]
+		if op.restore {
+			_ = ds.store.Put(op.key, op.value)
+			continue
+		}
+		_ = ds.store.AtomicDelete(op.key, &store.KVPair{Key: op.key, LastIndex: op.lastIndex})
+	}
+}
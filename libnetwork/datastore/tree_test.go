@@ -0,0 +1,124 @@
+package datastore
+
+import "testing"
+
+func TestBatchPutUpdatesObjectIndex(t *testing.T) {
+	ds := &Store{store: newMemStore()}
+
+	obj := &fakeKV{prefix: "test", name: "a", value: []byte("a")}
+
+	b := ds.NewBatch()
+	b.Put(obj)
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !obj.Exists() {
+		t.Fatal("Batch.Put did not mark the staged object as existing after commit")
+	}
+	if obj.Index() == 0 {
+		t.Fatal("Batch.Put did not call SetIndex on the staged object after commit")
+	}
+
+	// A second put using the object's now up-to-date Index must succeed;
+	// before this fix it spuriously failed with ErrKeyModified because the
+	// object's in-memory Index/Exists were never refreshed after the first
+	// commit, so this put was staged as if the key did not exist yet.
+	obj.value = []byte("a-updated")
+	b2 := ds.NewBatch()
+	b2.Put(obj)
+	if err := b2.Commit(); err != nil {
+		t.Fatalf("second Batch.Put failed, object Index was not kept in sync: %v", err)
+	}
+}
+
+func TestDeleteTreeRemovesChildren(t *testing.T) {
+	ds := &Store{store: newMemStore()}
+
+	parent := &fakeKV{prefix: "network"}
+	children := []*fakeKV{
+		{prefix: "network", name: "n1", value: []byte("n1")},
+		{prefix: "network", name: "n2", value: []byte("n2")},
+	}
+	for _, c := range children {
+		if err := ds.PutObjectAtomic(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.DeleteTree(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range children {
+		if _, err := ds.store.Get(Key(c.prefix, c.name)); err == nil {
+			t.Fatalf("DeleteTree left %q in the store", c.name)
+		}
+	}
+}
+
+// TestBatchCommitKeepsCacheInSync exercises the actual production
+// configuration - a non-nil ds.cache - since GetObject/List only ever
+// consult the cache once it is set, with no store fallback. It would not
+// have caught the stale-cache bug with ds.cache left nil.
+func TestBatchCommitKeepsCacheInSync(t *testing.T) {
+	ds := &Store{store: newMemStore()}
+	ds.cache = newCache(ds)
+
+	obj := &fakeKV{prefix: "test", name: "a", value: []byte("a")}
+	b := ds.NewBatch()
+	b.Put(obj)
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &fakeKV{prefix: "test", name: "a"}
+	if err := ds.GetObject(Key(got.Key()...), got); err != nil {
+		t.Fatalf("GetObject did not see the batch-committed object through the cache: %v", err)
+	}
+	if string(got.Value()) != "a" {
+		t.Fatalf("got %q, want %q", got.Value(), "a")
+	}
+
+	b2 := ds.NewBatch()
+	b2.Delete(obj)
+	if err := b2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.GetObject(Key(got.Key()...), &fakeKV{prefix: "test", name: "a"}); err == nil {
+		t.Fatal("GetObject still found the object through the cache after Batch.Delete committed")
+	}
+}
+
+// TestDeleteTreeKeepsCacheInSync is the DeleteTree analogue of
+// TestBatchCommitKeepsCacheInSync: it uses a real cache so that List, which
+// never falls back to the store once the cache is set, would have caught
+// children left visible after the tree was deleted.
+func TestDeleteTreeKeepsCacheInSync(t *testing.T) {
+	ds := &Store{store: newMemStore()}
+	ds.cache = newCache(ds)
+
+	parent := &fakeKV{prefix: "network"}
+	children := []*fakeKV{
+		{prefix: "network", name: "n1", value: []byte("n1")},
+		{prefix: "network", name: "n2", value: []byte("n2")},
+	}
+	for _, c := range children {
+		if err := ds.PutObjectAtomic(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.DeleteTree(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := ds.List(Key("network"), &fakeKV{prefix: "network"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("List still returned %d children through the cache after DeleteTree", len(list))
+	}
+}
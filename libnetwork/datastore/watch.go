@@ -0,0 +1,240 @@
+package datastore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+// defaultWatchInterval is the polling interval used by Watch/RestartWatch
+// when ScopeClientCfg.WatchInterval is unset.
+const defaultWatchInterval = 1 * time.Second
+
+// watchEntry tracks the last observed value and LastIndex of a single key,
+// so that successive polls can be diffed to discover adds, updates and
+// deletes.
+type watchEntry struct {
+	value     []byte
+	lastIndex uint64
+}
+
+// watch holds the state needed to keep a single Watch call alive across
+// RestartWatch calls.
+type watch struct {
+	prefix string
+	ctor   KVConstructor
+	outCh  chan KVObject
+	stopCh <-chan struct{}
+
+	mu      sync.Mutex
+	cancel  chan struct{}
+	entries map[string]watchEntry
+
+	// closing is closed exactly once, when stopCh fires, before outCh is
+	// closed. pollWatch selects on it around every send and at the start
+	// of every poll, so it never blocks on a full outCh, or races with,
+	// the close of outCh below.
+	closing chan struct{}
+}
+
+// Watch returns a channel of KVObject notifications for the subtree rooted
+// at kvObject.KeyPrefix(). The watch runs until stopCh is closed, at which
+// point the returned channel is closed.
+//
+// boltdb, the only backend kv store left, has no native change
+// notifications, so the watch is emulated: a background goroutine polls the
+// prefix on an interval (ScopeClientCfg.WatchInterval, default 1s) and diffs
+// the LastIndex of every child key against the previous poll. New or
+// modified objects are delivered as received from the store. Deleted
+// objects are delivered as a tombstone: the last known value is restored
+// onto a freshly constructed KVObject via SetValue, but SetIndex is never
+// called on it, so KVObject.Exists() reports false for it.
+func (ds *Store) Watch(kvObject KVObject, stopCh <-chan struct{}) (<-chan KVObject, error) {
+	ctor, ok := kvObject.(KVConstructor)
+	if !ok {
+		return nil, fmt.Errorf("error watching object, object does not implement KVConstructor interface")
+	}
+
+	w := &watch{
+		prefix:  Key(kvObject.KeyPrefix()...),
+		ctor:    ctor,
+		outCh:   make(chan KVObject, 16),
+		stopCh:  stopCh,
+		entries: make(map[string]watchEntry),
+		closing: make(chan struct{}),
+	}
+
+	ds.watchMu.Lock()
+	ds.watches = append(ds.watches, w)
+	ds.watchMu.Unlock()
+
+	ds.startWatch(w)
+
+	go func() {
+		<-stopCh
+
+		ds.watchMu.Lock()
+		for i, cur := range ds.watches {
+			if cur == w {
+				ds.watches = append(ds.watches[:i], ds.watches[i+1:]...)
+				break
+			}
+		}
+		ds.watchMu.Unlock()
+
+		// Signal first, without w.mu: this lets a pollWatch call that is
+		// already blocked trying to send on a full outCh abort right
+		// away, instead of holding w.mu forever waiting for a reader
+		// that is never coming back.
+		close(w.closing)
+
+		// Stop the poll loop, then wait for w.mu: since pollWatch holds
+		// it for an entire poll, acquiring it here guarantees no
+		// pollWatch call is still running once we release it - every
+		// later invocation will see w.closing already closed and return
+		// before attempting a send. Only then is it safe to close outCh.
+		w.mu.Lock()
+		close(w.cancel)
+		w.mu.Unlock()
+
+		close(w.outCh)
+	}()
+
+	return w.outCh, nil
+}
+
+// RestartWatch re-establishes every watch registered through Watch, for use
+// after a backend reconnect. Each watch's recorded LastIndex values are
+// reset, keeping the last known values, so the first poll after restart
+// reports every live key again and a tombstone for every key deleted in
+// the meantime, guaranteeing that no change missed while disconnected goes
+// unnoticed.
+func (ds *Store) RestartWatch() error {
+	ds.watchMu.Lock()
+	watches := make([]*watch, len(ds.watches))
+	copy(watches, ds.watches)
+	ds.watchMu.Unlock()
+
+	for _, w := range watches {
+		w.mu.Lock()
+		close(w.cancel)
+		for key, e := range w.entries {
+			w.entries[key] = watchEntry{value: e.value}
+		}
+		w.mu.Unlock()
+
+		ds.startWatch(w)
+	}
+
+	return nil
+}
+
+// startWatch (re)starts the polling goroutine for w.
+func (ds *Store) startWatch(w *watch) {
+	interval := ds.watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	w.mu.Lock()
+	cancel := make(chan struct{})
+	w.cancel = cancel
+	w.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-cancel:
+				return
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				ds.pollWatch(w)
+			}
+		}
+	}()
+}
+
+// pollWatch lists the keys under w.prefix, diffs them against w.entries and
+// delivers any adds, updates and deletes found on w.outCh.
+func (ds *Store) pollWatch(w *watch) {
+	select {
+	case <-w.closing:
+		return
+	default:
+	}
+
+	ds.mu.Lock()
+	current, err := ds.store.List(w.prefix)
+	ds.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// w.closing may have been closed while we were listing, or by the
+	// time we got w.mu, both above, in which case outCh may already be
+	// closed: bail out before the first send rather than race its close.
+	select {
+	case <-w.closing:
+		return
+	default:
+	}
+
+	seen := make(map[string]struct{}, len(current))
+	for _, pair := range current {
+		if len(pair.Value) == 0 {
+			continue
+		}
+
+		seen[pair.Key] = struct{}{}
+
+		if prev, ok := w.entries[pair.Key]; ok && prev.lastIndex == pair.LastIndex {
+			continue
+		}
+		w.entries[pair.Key] = watchEntry{value: pair.Value, lastIndex: pair.LastIndex}
+
+		o := w.ctor.New()
+		if err := o.SetValue(pair.Value); err != nil {
+			continue
+		}
+		o.SetIndex(pair.LastIndex)
+
+		// Gate the send on w.closing too, so a full outCh that nobody
+		// is draining anymore - the normal shape of "caller closed
+		// stopCh and stopped reading" - can't block this goroutine,
+		// and with it w.mu, forever.
+		select {
+		case w.outCh <- o:
+		case <-w.closing:
+			return
+		}
+	}
+
+	for key, prev := range w.entries {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		delete(w.entries, key)
+
+		// Deliver a tombstone: SetValue restores the last known content so
+		// callers can identify the deleted object, but SetIndex is
+		// deliberately not called, leaving Exists() == false.
+		o := w.ctor.New()
+		if err := o.SetValue(prev.value); err != nil {
+			continue
+		}
+		select {
+		case w.outCh <- o:
+		case <-w.closing:
+			return
+		}
+	}
+}
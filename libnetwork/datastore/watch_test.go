@@ -0,0 +1,59 @@
+package datastore
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWatchStopDoesNotPanicOrDeadlock exercises the race the poll goroutine
+// and the stop goroutine both hit around outCh: a very short poll interval
+// keeps pollWatch sending while nothing drains outCh, so by the time stopCh
+// is closed a send is either in flight or about to start. Before the fix,
+// that interleaving could panic on a send to a closed channel, or deadlock
+// the poll goroutine (and with it w.mu) forever.
+func TestWatchStopDoesNotPanicOrDeadlock(t *testing.T) {
+	ds := &Store{store: newMemStore(), watchInterval: time.Millisecond}
+
+	obj := &fakeKV{prefix: "endpoint", name: "e1", value: []byte("v1")}
+	if err := ds.PutObjectAtomic(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	stopCh := make(chan struct{})
+	outCh, err := ds.Watch(&fakeKV{prefix: "endpoint"}, stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deliberately do not drain outCh, so its 16-slot buffer fills and
+	// every later send in pollWatch blocks - the exact condition that used
+	// to deadlock the poller (and so w.mu) once stopCh closed.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 64; i++ {
+			obj.value = []byte{byte(i)}
+			_ = ds.PutObjectAtomic(obj)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// Leave enough 1ms polls, each seeing a fresh update, to fill the
+	// 16-slot buffer before stopping.
+	time.Sleep(50 * time.Millisecond)
+	close(stopCh)
+	wg.Wait()
+
+	select {
+	case <-outCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outCh was never closed after stopCh fired - poll goroutine likely deadlocked")
+	}
+
+	// Drain until the channel reports closed, confirming Watch's stop
+	// goroutine reached close(w.outCh) without panicking.
+	for range outCh {
+	}
+}
@@ -0,0 +1,163 @@
+// Package consul implements the store.Store interface backed by Consul, so
+// that libnetwork can use a Consul cluster as a GlobalScope or SwarmScope
+// datastore for multi-host overlay networks.
+package consul
+
+import (
+	"fmt"
+	"strings"
+
+	consulapi "github.com/hashicorp/consul/api"
+
+	"github.com/docker/docker/libnetwork/datastore"
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+func init() {
+	// Blank-importing this package makes "consul" a valid
+	// ScopeClientCfg.Provider.
+	datastore.Register("consul", New)
+}
+
+// Store is a store.Store implementation backed by a Consul cluster's KV
+// store.
+type Store struct {
+	client *consulapi.Client
+	kv     *consulapi.KV
+	bucket string
+}
+
+// New creates a new Store backed by the Consul agent reachable at the
+// first of addrs.
+func New(addrs []string, cfg *store.Config) (store.Store, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("consul: at least one address is required")
+	}
+	if cfg == nil {
+		cfg = &store.Config{}
+	}
+
+	clientCfg := consulapi.DefaultConfig()
+	clientCfg.Address = addrs[0]
+	if cfg.ConnectionTimeout > 0 {
+		clientCfg.WaitTime = cfg.ConnectionTimeout
+	}
+	if cfg.Username != "" {
+		clientCfg.HttpAuth = &consulapi.HttpBasicAuth{Username: cfg.Username, Password: cfg.Password}
+	}
+	if cfg.TLS != nil {
+		clientCfg.Transport.TLSClientConfig = cfg.TLS
+	}
+
+	cli, err := consulapi.NewClient(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("consul: failed to create client: %w", err)
+	}
+
+	return &Store{client: cli, kv: cli.KV(), bucket: cfg.Bucket}, nil
+}
+
+func (s *Store) key(key string) string {
+	if s.bucket == "" {
+		return key
+	}
+	return s.bucket + "/" + key
+}
+
+// unkey strips the bucket prefix added by key, so callers always see the
+// same key they would have passed to Get/Put, regardless of bucket.
+func (s *Store) unkey(fullKey string) string {
+	if s.bucket == "" {
+		return fullKey
+	}
+	return strings.TrimPrefix(fullKey, s.bucket+"/")
+}
+
+// Get retrieves a single key/value pair from Consul.
+func (s *Store) Get(key string) (*store.KVPair, error) {
+	pair, _, err := s.kv.Get(s.key(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, store.ErrKeyNotFound
+	}
+	return &store.KVPair{Key: key, Value: pair.Value, LastIndex: pair.ModifyIndex}, nil
+}
+
+// Put writes a value to Consul, creating the key if it does not yet exist.
+func (s *Store) Put(key string, value []byte) error {
+	_, err := s.kv.Put(&consulapi.KVPair{Key: s.key(key), Value: value}, nil)
+	return err
+}
+
+// Exists reports whether key is present in Consul.
+func (s *Store) Exists(key string) (bool, error) {
+	pair, _, err := s.kv.Get(s.key(key), nil)
+	if err != nil {
+		return false, err
+	}
+	return pair != nil, nil
+}
+
+// List returns every key/value pair stored under the directory prefix.
+func (s *Store) List(directory string) ([]*store.KVPair, error) {
+	pairs, _, err := s.kv.List(s.key(directory), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*store.KVPair, 0, len(pairs))
+	for _, pair := range pairs {
+		out = append(out, &store.KVPair{Key: s.unkey(pair.Key), Value: pair.Value, LastIndex: pair.ModifyIndex})
+	}
+	return out, nil
+}
+
+// AtomicPut writes value to key, using Consul's check-and-set semantics so
+// the write fails with store.ErrKeyExists if the key's ModifyIndex no
+// longer matches previous.LastIndex.
+func (s *Store) AtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error) {
+	fullKey := s.key(key)
+
+	pair := &consulapi.KVPair{Key: fullKey, Value: value}
+	if previous != nil {
+		pair.ModifyIndex = previous.LastIndex
+	}
+
+	ok, _, err := s.kv.CAS(pair, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, store.ErrKeyExists
+	}
+
+	result, _, err := s.kv.Get(fullKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &store.KVPair{Key: key, Value: value, LastIndex: result.ModifyIndex}, nil
+}
+
+// AtomicDelete removes key, using Consul's check-and-set semantics so the
+// delete fails with store.ErrKeyExists if the key's ModifyIndex no longer
+// matches previous.LastIndex.
+func (s *Store) AtomicDelete(key string, previous *store.KVPair) error {
+	if previous == nil {
+		return fmt.Errorf("consul: previous value is required for atomic delete")
+	}
+
+	ok, _, err := s.kv.DeleteCAS(&consulapi.KVPair{Key: s.key(key), ModifyIndex: previous.LastIndex}, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return store.ErrKeyExists
+	}
+	return nil
+}
+
+// Close is a no-op: the Consul API client does not hold a persistent
+// connection that needs to be released.
+func (s *Store) Close() {}
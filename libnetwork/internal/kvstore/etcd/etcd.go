@@ -0,0 +1,165 @@
+// Package etcd implements the store.Store interface backed by etcd, so
+// that libnetwork can use an etcd cluster as a GlobalScope or SwarmScope
+// datastore for multi-host overlay networks.
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/docker/docker/libnetwork/datastore"
+	store "github.com/docker/docker/libnetwork/internal/kvstore"
+)
+
+func init() {
+	// Blank-importing this package makes "etcd" a valid
+	// ScopeClientCfg.Provider.
+	datastore.Register("etcd", New)
+}
+
+// Store is a store.Store implementation backed by an etcd cluster.
+type Store struct {
+	client *clientv3.Client
+	bucket string
+}
+
+// New creates a new Store backed by the etcd cluster reachable at addrs.
+func New(addrs []string, cfg *store.Config) (store.Store, error) {
+	if cfg == nil {
+		cfg = &store.Config{}
+	}
+
+	clientCfg := clientv3.Config{
+		Endpoints:   addrs,
+		DialTimeout: cfg.ConnectionTimeout,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+	}
+	if cfg.TLS != nil {
+		clientCfg.TLS = cfg.TLS
+	}
+
+	cli, err := clientv3.New(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("etcd: failed to create client: %w", err)
+	}
+
+	return &Store{client: cli, bucket: cfg.Bucket}, nil
+}
+
+func (s *Store) key(key string) string {
+	if s.bucket == "" {
+		return key
+	}
+	return s.bucket + "/" + key
+}
+
+// unkey strips the bucket prefix added by key, so callers always see the
+// same key they would have passed to Get/Put, regardless of bucket.
+func (s *Store) unkey(fullKey string) string {
+	if s.bucket == "" {
+		return fullKey
+	}
+	return strings.TrimPrefix(fullKey, s.bucket+"/")
+}
+
+// Get retrieves a single key/value pair from etcd.
+func (s *Store) Get(key string) (*store.KVPair, error) {
+	resp, err := s.client.Get(context.Background(), s.key(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+	kv := resp.Kvs[0]
+	return &store.KVPair{Key: key, Value: kv.Value, LastIndex: uint64(kv.ModRevision)}, nil
+}
+
+// Put writes a value to etcd, creating the key if it does not yet exist.
+func (s *Store) Put(key string, value []byte) error {
+	_, err := s.client.Put(context.Background(), s.key(key), string(value))
+	return err
+}
+
+// Exists reports whether key is present in etcd.
+func (s *Store) Exists(key string) (bool, error) {
+	resp, err := s.client.Get(context.Background(), s.key(key))
+	if err != nil {
+		return false, err
+	}
+	return len(resp.Kvs) > 0, nil
+}
+
+// List returns every key/value pair stored under the directory prefix.
+func (s *Store) List(directory string) ([]*store.KVPair, error) {
+	resp, err := s.client.Get(context.Background(), s.key(directory), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*store.KVPair, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		out = append(out, &store.KVPair{
+			Key:       s.unkey(string(kv.Key)),
+			Value:     kv.Value,
+			LastIndex: uint64(kv.ModRevision),
+		})
+	}
+	return out, nil
+}
+
+// AtomicPut writes value to key, failing with store.ErrKeyExists if the
+// key's ModRevision no longer matches previous.LastIndex.
+func (s *Store) AtomicPut(key string, value []byte, previous *store.KVPair) (*store.KVPair, error) {
+	fullKey := s.key(key)
+
+	var cmp clientv3.Cmp
+	if previous == nil {
+		cmp = clientv3.Compare(clientv3.CreateRevision(fullKey), "=", 0)
+	} else {
+		cmp = clientv3.Compare(clientv3.ModRevision(fullKey), "=", int64(previous.LastIndex))
+	}
+
+	resp, err := s.client.Txn(context.Background()).
+		If(cmp).
+		Then(clientv3.OpPut(fullKey, string(value))).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, store.ErrKeyExists
+	}
+
+	return &store.KVPair{Key: key, Value: value, LastIndex: uint64(resp.Header.Revision)}, nil
+}
+
+// AtomicDelete removes key, failing with store.ErrKeyExists if the key's
+// ModRevision no longer matches previous.LastIndex.
+func (s *Store) AtomicDelete(key string, previous *store.KVPair) error {
+	if previous == nil {
+		return fmt.Errorf("etcd: previous value is required for atomic delete")
+	}
+
+	fullKey := s.key(key)
+	resp, err := s.client.Txn(context.Background()).
+		If(clientv3.Compare(clientv3.ModRevision(fullKey), "=", int64(previous.LastIndex))).
+		Then(clientv3.OpDelete(fullKey)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return store.ErrKeyExists
+	}
+	return nil
+}
+
+// Close releases the underlying etcd client connection.
+func (s *Store) Close() {
+	s.client.Close()
+}